@@ -0,0 +1,108 @@
+// Package auth provides AuthProvider implementations for the connect-phase
+// challenge/response schemes used by common RTMP servers.
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	gortmp "github.com/HidenXL/gortmp"
+	"github.com/elobuff/goamf"
+)
+
+// Adobe implements the challenge/opaque/salt MD5 scheme used by Adobe
+// Flash Media Server and Wowza when configured for "adobe" auth mode.
+// The exchange is exactly two rounds: the server challenges once with a
+// salt (+opaque), and Adobe answers with a computed response; client.connect
+// treats HandleChallenge's done=true as final, so a server that rejects
+// the response surfaces as the generic "rejected" error from connect
+// rather than a second call into HandleChallenge.
+type Adobe struct {
+	User     string
+	Password string
+}
+
+func NewAdobe(user, password string) *Adobe {
+	return &Adobe{User: user, Password: password}
+}
+
+func (a *Adobe) Prepare(u *url.URL) (amf.Object, error) {
+	return amf.Object{}, nil
+}
+
+func (a *Adobe) HandleChallenge(response *gortmp.Response) (amf.Object, bool, error) {
+	desc := response.Description()
+
+	params := parseAuthParams(desc)
+
+	salt, ok := params["salt"]
+	if !ok {
+		return nil, false, fmt.Errorf("auth: adobe: unexpected challenge: %s", desc)
+	}
+
+	challenge, ok := params["challenge"]
+	if !ok {
+		challenge = randomChallenge()
+	}
+	opaque := params["opaque"]
+
+	hash := a.challengeHash(salt, opaque, challenge)
+
+	retry := amf.Object{
+		"authmod":   "adobe",
+		"user":      a.User,
+		"challenge": challenge,
+		"response":  hash,
+	}
+	if opaque != "" {
+		retry["opaque"] = opaque
+	}
+
+	return retry, true, nil
+}
+
+func randomChallenge() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (a *Adobe) challengeHash(salt, opaque, challenge string) string {
+	h1 := md5sum(salt + a.User + a.Password)
+	payload := h1 + opaque + challenge
+	h2 := md5sum(payload)
+	return h2
+}
+
+func md5sum(s string) string {
+	sum := md5.Sum([]byte(s))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// parseAuthParams extracts the query-style ?k=v&k2=v2 parameters that
+// Adobe/Wowza/Nimble append to the NetConnection.Connect.Rejected
+// description string.
+func parseAuthParams(desc string) map[string]string {
+	params := map[string]string{}
+
+	idx := strings.Index(desc, "?")
+	if idx == -1 {
+		return params
+	}
+
+	query := desc[idx+1:]
+	for _, kv := range strings.Split(query, "&") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		params[parts[0]] = parts[1]
+	}
+
+	return params
+}
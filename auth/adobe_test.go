@@ -0,0 +1,40 @@
+package auth
+
+import "testing"
+
+func TestAdobeChallengeHashDeterministic(t *testing.T) {
+	a := NewAdobe("bob", "secret")
+
+	h1 := a.challengeHash("saltvalue", "opaquevalue", "challengevalue")
+	h2 := a.challengeHash("saltvalue", "opaquevalue", "challengevalue")
+
+	if h1 != h2 {
+		t.Fatalf("challengeHash is not deterministic: %q != %q", h1, h2)
+	}
+	if h1 == "" {
+		t.Fatal("challengeHash returned empty string")
+	}
+}
+
+func TestAdobeChallengeHashVariesWithInput(t *testing.T) {
+	a := NewAdobe("bob", "secret")
+
+	h1 := a.challengeHash("salt1", "opaque", "challenge")
+	h2 := a.challengeHash("salt2", "opaque", "challenge")
+
+	if h1 == h2 {
+		t.Fatal("challengeHash should differ when salt differs")
+	}
+}
+
+func TestRandomChallengeIsHex(t *testing.T) {
+	c := randomChallenge()
+	if len(c) != 16 {
+		t.Fatalf("randomChallenge() = %q, want 16 hex characters", c)
+	}
+	for _, r := range c {
+		if !((r >= '0' && r <= '9') || (r >= 'a' && r <= 'f')) {
+			t.Fatalf("randomChallenge() = %q, not hex", c)
+		}
+	}
+}
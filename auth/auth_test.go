@@ -0,0 +1,35 @@
+package auth
+
+import "testing"
+
+func TestParseAuthParams(t *testing.T) {
+	tests := []struct {
+		desc string
+		want map[string]string
+	}{
+		{
+			desc: "?reason=needauth&user=bob&salt=abcd&opaque=xyz",
+			want: map[string]string{"reason": "needauth", "user": "bob", "salt": "abcd", "opaque": "xyz"},
+		},
+		{
+			desc: "?reason=needauth&nonce=0123456789abcdef",
+			want: map[string]string{"reason": "needauth", "nonce": "0123456789abcdef"},
+		},
+		{
+			desc: "no query string here",
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		got := parseAuthParams(tt.desc)
+		if len(got) != len(tt.want) {
+			t.Fatalf("parseAuthParams(%q) = %v, want %v", tt.desc, got, tt.want)
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("parseAuthParams(%q)[%q] = %q, want %q", tt.desc, k, got[k], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import "testing"
+
+func TestHexMd5(t *testing.T) {
+	// Known MD5("") value.
+	got := hexMd5("")
+	want := "d41d8cd98f00b204e9800998ecf8427e"
+	if got != want {
+		t.Fatalf("hexMd5(\"\") = %q, want %q", got, want)
+	}
+}
+
+func TestNewLlnwLeavesEmptyMethodUnchanged(t *testing.T) {
+	l := NewLlnw("bob", "secret", "live", "")
+	if l.Method != "" {
+		t.Fatalf("NewLlnw should pass Method through unchanged, got %q", l.Method)
+	}
+}
+
+func TestLlnwHA2VariesWithMethod(t *testing.T) {
+	// HA2 is method + ":" + app, so "publish" and "play" must hash
+	// differently - this is what review comment about the hardcoded
+	// "publish" HA2 was about.
+	publishHA2 := hexMd5("publish" + ":" + "live")
+	playHA2 := hexMd5("play" + ":" + "live")
+
+	if publishHA2 == playHA2 {
+		t.Fatal("HA2 should differ between publish and play methods")
+	}
+}
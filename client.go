@@ -1,6 +1,7 @@
 package rtmp
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"github.com/elobuff/goamf"
@@ -18,10 +19,20 @@ var (
 type Client struct {
 	url string
 
+	// TLSConfig, if set, is used for rtmps:// connections instead of the
+	// default (InsecureSkipVerify) configuration.
+	TLSConfig *tls.Config
+
 	connected bool
 
 	conn net.Conn
 
+	// outBytes/outWindowSize/outChunkSize/inBytes/inWindowSize/inChunkSize
+	// are read from Stats (any goroutine) while sendLoop/receiveLoop read
+	// them on every message and handleProtocolMessage/SetOutChunkSize/
+	// SetAckWindowSize write them from the route loop or a caller - all
+	// access goes through sync/atomic instead of a mutex to keep the hot
+	// per-message path lock-free.
 	outBytes        uint32
 	outMessages     chan *Message
 	outWindowSize   uint32
@@ -34,19 +45,58 @@ type Client struct {
 	inChunkSize     uint32
 	inChunkStreams  map[uint32]*InboundChunkStream
 
-	responses      map[uint32]*Response
-	responsesMutex sync.Mutex
+	pendingRequests map[uint32]chan pendingResult
+	pendingMutex    sync.Mutex
+
+	enc amf.Encoder
+	dec amf.Decoder
+
+	streams      map[uint32]mediaSink
+	streamsMutex sync.Mutex
 
 	lastTransactionId uint32
 	connectionId      string
 
 	amfExternalHandlers map[string]amf.ExternalHandler
+
+	authProviders map[string]AuthProvider
+
+	reconnectPolicy *ReconnectPolicy
+	reconnectMutex  sync.Mutex
+	listeners       []ConnectionListener
+	listenersMutex  sync.Mutex
+	connectedMutex  sync.Mutex
+
+	// resetMutex guards loopDone (plus the outMessages/quit snapshot taken
+	// by SendMessage and the loopDone snapshot taken by supervise) against
+	// Reset: loopExited, SendMessage and supervise only ever touch those
+	// fields while holding it, so Reset can never close/reassign one out
+	// from under a concurrent send. receiveLoop/sendLoop/routeLoop take
+	// their inMessages/outMessages/quit snapshot when they start, without
+	// holding it.
+	resetMutex sync.Mutex
+	loopDone   chan error
+
+	// quit is closed by Reset to tell receiveLoop/sendLoop/routeLoop (and
+	// any SendMessage blocked handing off to outMessages) that this
+	// generation is done, without ever closing outMessages/inMessages
+	// themselves - a channel only loopExited's owner closes is never at
+	// risk of a concurrent send-on-closed-channel panic.
+	quit chan struct{}
+
+	metricsMutex        sync.Mutex
+	metricsSink         MetricsSink
+	chunkStreamMessages map[uint32]uint64
+
+	pingMutex    sync.Mutex
+	pendingPings map[uint32]time.Time
 }
 
 func NewClient(url string) (c *Client) {
 	c = &Client{
 		url:                 url,
 		amfExternalHandlers: make(map[string]amf.ExternalHandler),
+		authProviders:       make(map[string]AuthProvider),
 	}
 
 	c.Reset()
@@ -55,41 +105,76 @@ func NewClient(url string) (c *Client) {
 }
 
 func (c *Client) IsAlive() bool {
-	if c.connected != true {
-		return false
-	}
-
-	return true
+	c.connectedMutex.Lock()
+	defer c.connectedMutex.Unlock()
+	return c.connected
 }
 
+// Reset tears down the connection and reinitializes Client state so it can
+// Connect again. It is safe to call concurrently (e.g. from Disconnect and
+// the reconnect supervisor racing each other) and safe to call more than
+// once in a row: resetMutex serializes resets, so a channel is only ever
+// closed by the call that owns it, never re-closed by a racing caller.
+//
+// resetMutex also serializes against loopExited and SendMessage, which
+// read loopDone and outMessages/quit under the same lock: outMessages
+// and inMessages are never closed (only abandoned), so the loops and
+// SendMessage wake up via quit instead of racing a close against a send.
+//
+// pendingRequests/streams/chunkStreamMessages/pendingPings are reassigned
+// here too; each reassignment takes the same mutex every other access path
+// to that map uses (pendingMutex/streamsMutex/metricsMutex/pingMutex), so a
+// reconnect's Reset can't race a concurrent read/write of one of those maps.
 func (c *Client) Reset() {
-	c.connected = false
+	c.resetMutex.Lock()
+	defer c.resetMutex.Unlock()
+
+	c.setConnected(false)
 
 	if c.conn != nil {
 		c.conn.Close()
+		c.conn = nil
 	}
 
-	if c.outMessages != nil {
-		close(c.outMessages)
+	if c.quit != nil {
+		close(c.quit)
 	}
 
-	if c.inMessages != nil {
-		close(c.inMessages)
+	if c.loopDone != nil {
+		close(c.loopDone)
 	}
 
-	c.outBytes = 0
+	atomic.StoreUint32(&c.outBytes, 0)
 	c.outMessages = make(chan *Message)
-	c.outChunkSize = DEFAULT_CHUNK_SIZE
-	c.outWindowSize = DEFAULT_WINDOW_SIZE
+	atomic.StoreUint32(&c.outChunkSize, DEFAULT_CHUNK_SIZE)
+	atomic.StoreUint32(&c.outWindowSize, DEFAULT_WINDOW_SIZE)
 	c.outChunkStreams = make(map[uint32]*OutboundChunkStream)
-	c.inBytes = 0
+	atomic.StoreUint32(&c.inBytes, 0)
 	c.inMessages = make(chan *Message)
-	c.inChunkSize = DEFAULT_CHUNK_SIZE
-	c.inWindowSize = DEFAULT_WINDOW_SIZE
+	atomic.StoreUint32(&c.inChunkSize, DEFAULT_CHUNK_SIZE)
+	atomic.StoreUint32(&c.inWindowSize, DEFAULT_WINDOW_SIZE)
 	c.inChunkStreams = make(map[uint32]*InboundChunkStream)
-	c.responses = make(map[uint32]*Response)
+
+	c.pendingMutex.Lock()
+	c.pendingRequests = make(map[uint32]chan pendingResult)
+	c.pendingMutex.Unlock()
+
+	c.streamsMutex.Lock()
+	c.streams = make(map[uint32]mediaSink)
+	c.streamsMutex.Unlock()
+
+	c.metricsMutex.Lock()
+	c.chunkStreamMessages = make(map[uint32]uint64)
+	c.metricsMutex.Unlock()
+
+	c.pingMutex.Lock()
+	c.pendingPings = make(map[uint32]time.Time)
+	c.pingMutex.Unlock()
+
 	c.lastTransactionId = 0
 	c.connectionId = ""
+	c.loopDone = make(chan error, 3)
+	c.quit = make(chan struct{})
 }
 
 func (c *Client) RegisterExternalHandler(name string, fn amf.ExternalHandler) {
@@ -122,7 +207,10 @@ func (c *Client) Connect() (err error) {
 			return err
 		}
 
-		config := &tls.Config{InsecureSkipVerify: true}
+		config := c.TLSConfig
+		if config == nil {
+			config = &tls.Config{InsecureSkipVerify: true}
+		}
 		tc := tls.Client(nc, config)
 		err = tc.Handshake()
 		if err != nil {
@@ -153,11 +241,15 @@ func (c *Client) Connect() (err error) {
 		return Error("client connect: could not complete connect: %s", err)
 	}
 
-	c.connected = true
+	c.setConnected(true)
 	c.connectionId = id
 
+	go c.supervise()
+
 	log.Debug("connected to %s (%s)", c.url, c.connectionId)
 
+	c.notifyConnected()
+
 	return
 }
 
@@ -165,56 +257,145 @@ func (c *Client) NextTransactionId() uint32 {
 	return atomic.AddUint32(&c.lastTransactionId, 1)
 }
 
-func (c *Client) GetResponse(tid uint32) (response *Response, ready bool) {
-	c.responsesMutex.Lock()
-	defer c.responsesMutex.Unlock()
-	response = c.responses[tid]
-	if response != nil {
-		ready = true
-		delete(c.responses, tid)
+func (c *Client) SendMessage(msg *Message) {
+	c.recordMessage(msg.ChunkStreamId, msg.Type)
+
+	c.resetMutex.Lock()
+	outMessages := c.outMessages
+	quit := c.quit
+	c.resetMutex.Unlock()
+
+	select {
+	case outMessages <- msg:
+	case <-quit:
 	}
-	return
 }
 
-func (c *Client) SendMessage(msg *Message) {
-	c.outMessages <- msg
+// pendingResult is what a registered Call waiter receives: either a
+// decoded Response, or an error if the connection dropped before one
+// arrived.
+type pendingResult struct {
+	response *Response
+	err      error
 }
 
-func (c *Client) Call(msg *Message, t uint32) (response *Response, err error) {
-	c.SendMessage(msg)
+// register creates and returns the channel that routeCommandMessage (or
+// failPendingCalls, on connection loss) will deliver the result for tid
+// on, along with a cleanup func that must run once the caller is done
+// waiting so pendingRequests doesn't leak.
+func (c *Client) register(tid uint32) (ch chan pendingResult, cleanup func()) {
+	ch = make(chan pendingResult, 1)
+
+	c.pendingMutex.Lock()
+	c.pendingRequests[tid] = ch
+	c.pendingMutex.Unlock()
+
+	cleanup = func() {
+		c.pendingMutex.Lock()
+		delete(c.pendingRequests, tid)
+		c.pendingMutex.Unlock()
+	}
 
+	return ch, cleanup
+}
+
+// deliverResponse hands response to the waiter registered for its
+// transaction id, if any is still waiting. It is called by
+// routeCommandMessage in place of the old responses map.
+func (c *Client) deliverResponse(tid uint32, response *Response) bool {
+	c.pendingMutex.Lock()
+	ch, ok := c.pendingRequests[tid]
+	if ok {
+		delete(c.pendingRequests, tid)
+	}
+	c.pendingMutex.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	ch <- pendingResult{response: response}
+	return true
+}
+
+// Call sends msg and blocks until a matching response arrives, ctx is
+// done, or the connection is lost, whichever happens first.
+func (c *Client) Call(ctx context.Context, msg *Message) (*Response, error) {
 	tid := msg.TransactionId
+	ch, cleanup := c.register(tid)
+	defer cleanup()
+
+	c.SendMessage(msg)
 
-	ticker := time.NewTicker(time.Duration(5) * time.Millisecond)
-	defer ticker.Stop()
+	select {
+	case result := <-ch:
+		return result.response, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// AsyncResult is what CallAsync's channel delivers: exactly one of
+// Response or Err is set, matching whichever the registered waiter
+// received from deliverResponse/failPendingCalls, or ctx.Err() if ctx is
+// done first.
+type AsyncResult struct {
+	Response *Response
+	Err      error
+}
+
+// CallAsync sends msg and returns immediately with a channel that will
+// receive exactly one AsyncResult: the response, or an error if ctx is
+// done or the connection is lost before one arrives. Use this for
+// fire-and-forget commands whose result the caller wants to observe
+// without blocking.
+func (c *Client) CallAsync(ctx context.Context, msg *Message) (<-chan AsyncResult, error) {
+	tid := msg.TransactionId
+	ch, cleanup := c.register(tid)
 
-	timeout := time.After(time.Duration(t) * time.Second)
+	c.SendMessage(msg)
 
-	for {
+	out := make(chan AsyncResult, 1)
+	go func() {
+		defer cleanup()
 		select {
-		case <-ticker.C:
-			res, ready := c.GetResponse(tid)
-			if ready {
-				return res, nil
-			}
-		case <-timeout:
-			return response, ErrResponseTimeout
+		case result := <-ch:
+			out <- AsyncResult{Response: result.response, Err: result.err}
+		case <-ctx.Done():
+			out <- AsyncResult{Err: ctx.Err()}
 		}
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// CallTimeout is a compatibility shim for the pre-context Call(msg, t)
+// signature: it blocks for at most t seconds waiting for a response.
+func (c *Client) CallTimeout(msg *Message, t uint32) (*Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t)*time.Second)
+	defer cancel()
+
+	res, err := c.Call(ctx, msg)
+	if err == context.DeadlineExceeded {
+		return nil, ErrResponseTimeout
 	}
 
-	return
+	return res, err
 }
 
 func (c *Client) Read(p []byte) (n int, err error) {
 	n, err = c.conn.Read(p)
-	c.inBytes += uint32(n)
+	atomic.AddUint32(&c.inBytes, uint32(n))
+	c.recordBytes(uint32(n), 0)
 	log.Trace("read %d", n)
 	return n, err
 }
 
 func (c *Client) Write(p []byte) (n int, err error) {
 	n, err = c.conn.Write(p)
-	c.outBytes += uint32(n)
+	atomic.AddUint32(&c.outBytes, uint32(n))
+	c.recordBytes(0, uint32(n))
 	log.Trace("write %d", n)
 	return n, err
 }
@@ -0,0 +1,61 @@
+package rtmp
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/elobuff/goamf"
+)
+
+// AuthProvider participates in the AMF connect handshake for a given
+// authentication scheme (e.g. "adobe", "llnw"). Prepare is called before
+// the initial connect command is sent and may contribute extra connect
+// parameters. If the server rejects the connect with a
+// NetConnection.Connect.Rejected description carrying ?authmod=<scheme>,
+// HandleChallenge is called with that response so the provider can derive
+// the parameters for a follow-up connect attempt.
+type AuthProvider interface {
+	Prepare(u *url.URL) (extraConnectParams amf.Object, err error)
+	HandleChallenge(response *Response) (retryConnectParams amf.Object, done bool, err error)
+}
+
+// RegisterAuthProvider registers p to handle the connect-phase challenge
+// for scheme. scheme matches the authmod= value reported by the server
+// (e.g. "adobe", "llnw").
+func (c *Client) RegisterAuthProvider(scheme string, p AuthProvider) {
+	c.authProviders[scheme] = p
+}
+
+// authProviderForURL returns the provider registered for the scheme named
+// by the "authmod" query parameter on the connect URL, if any. This only
+// covers the case where the caller already knows the server's auth mode
+// ahead of time; the common case is learning it from the server's
+// rejection via authSchemeFromDescription instead.
+func (c *Client) authProviderForURL(u *url.URL) (scheme string, p AuthProvider) {
+	scheme = u.Query().Get("authmod")
+	if scheme == "" {
+		return "", nil
+	}
+	return scheme, c.authProviders[scheme]
+}
+
+// authSchemeFromDescription extracts the "authmod" value from a
+// NetConnection.Connect.Rejected description, which Adobe/Wowza/Nimble
+// servers append as query-style ?authmod=<scheme>&... parameters, so
+// connect() can pick an AuthProvider without the caller having to already
+// know the server's auth mode.
+func authSchemeFromDescription(desc string) string {
+	idx := strings.Index(desc, "?")
+	if idx == -1 {
+		return ""
+	}
+
+	for _, kv := range strings.Split(desc[idx+1:], "&") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && parts[0] == "authmod" {
+			return parts[1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,64 @@
+package rtmp
+
+// newCommandMessage builds an AMF0 command message of the form
+// [name, transactionId, null, ...args] on the given chunk stream / NetStream
+// id, assigning it a fresh transaction id so the caller can Call() it.
+func (c *Client) newCommandMessage(csid, streamId uint32, name string, args []interface{}) (*Message, error) {
+	tid := c.NextTransactionId()
+
+	msg, err := NewCommandMessage(csid, streamId, tid, name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// sendCommand fires an AMF0 command with transaction id 0 (no response
+// expected), as used by NetStream commands like play/publish/pause/seek.
+func (c *Client) sendCommand(csid, streamId uint32, name string, args ...interface{}) error {
+	msg, err := NewCommandMessage(csid, streamId, 0, name, args)
+	if err != nil {
+		return err
+	}
+
+	c.SendMessage(msg)
+	return nil
+}
+
+// sendMediaMessage sends a single raw audio/video tag body on streamId.
+func (c *Client) sendMediaMessage(msgType uint8, streamId uint32, ts uint32, data []byte) error {
+	msg, err := NewMediaMessage(msgType, streamId, ts, data)
+	if err != nil {
+		return err
+	}
+
+	c.SendMessage(msg)
+	return nil
+}
+
+// sendProtocolControlMessage sends a Protocol Control Message (chunk
+// stream 2, message stream 0) carrying a single uint32 payload, as used
+// by Set Chunk Size (type 1) and Window Acknowledgement Size (type 5).
+func (c *Client) sendProtocolControlMessage(msgType uint8, value uint32) error {
+	msg, err := NewProtocolControlMessage(msgType, value)
+	if err != nil {
+		return err
+	}
+
+	c.SendMessage(msg)
+	return nil
+}
+
+// sendUserControlMessage sends a Type 4 User Control Message with the
+// given event type and a single uint32 payload, as used by Ping Request
+// (event 6) and Ping Response (event 7).
+func (c *Client) sendUserControlMessage(event uint16, value uint32) error {
+	msg, err := NewUserControlMessage(event, value)
+	if err != nil {
+		return err
+	}
+
+	c.SendMessage(msg)
+	return nil
+}
@@ -0,0 +1,87 @@
+package rtmp
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/elobuff/goamf"
+)
+
+// connect sends the AMF "connect" command and drives it through to
+// NetConnection.Connect.Success, returning the server-assigned connection
+// id. If the connect URL carries an authmod= query parameter matching a
+// registered AuthProvider, that provider's Prepare params are merged into
+// the initial connect; otherwise the provider is picked once the server
+// first rejects the connect, from the authmod= it reports in the
+// NetConnection.Connect.Rejected description. Either way, the rejection is
+// handed to HandleChallenge and retried until the provider reports done
+// or the server accepts or rejects for good.
+func (c *Client) connect() (string, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return "", err
+	}
+
+	params := amf.Object{
+		"app":      strings.TrimPrefix(u.Path, "/"),
+		"tcUrl":    c.url,
+		"fpad":     false,
+		"flashVer": "LNX 9,0,124,2",
+	}
+
+	_, provider := c.authProviderForURL(u)
+	if provider != nil {
+		extra, err := provider.Prepare(u)
+		if err != nil {
+			return "", Error("client connect: auth provider prepare failed: %s", err)
+		}
+		for k, v := range extra {
+			params[k] = v
+		}
+	}
+
+	authDone := false
+
+	for {
+		msg, err := c.newCommandMessage(CHUNK_STREAM_ID_COMMAND, 0, "connect", []interface{}{params})
+		if err != nil {
+			return "", err
+		}
+
+		res, err := c.Call(context.Background(), msg)
+		if err != nil {
+			return "", err
+		}
+
+		code := res.Code()
+
+		if code == "NetConnection.Connect.Success" {
+			return res.ConnectionId(), nil
+		}
+
+		if code == "NetConnection.Connect.Rejected" && provider == nil && !authDone {
+			provider = c.authProviders[authSchemeFromDescription(res.Description())]
+		}
+
+		if code == "NetConnection.Connect.Rejected" && provider != nil {
+			retryParams, done, err := provider.HandleChallenge(res)
+			if err != nil {
+				return "", Error("client connect: auth challenge failed: %s", err)
+			}
+
+			for k, v := range retryParams {
+				params[k] = v
+			}
+
+			if done {
+				provider = nil // the next round must be success or a real failure
+				authDone = true
+			}
+
+			continue
+		}
+
+		return "", Error("client connect: rejected: %s", res.Description())
+	}
+}
@@ -0,0 +1,51 @@
+package rtmp
+
+import "sync/atomic"
+
+// receiveLoop reads chunks off the wire, reassembles them into messages
+// per chunk stream, and hands completed messages to routeLoop via
+// inMessages. It exits and reports the error via loopExited when the
+// connection drops, or silently once quit is closed by Reset. inMessages
+// is never closed (only abandoned on Reset), so handing off a message and
+// waking up on quit share a single select instead of racing a close.
+func (c *Client) receiveLoop() {
+	inMessages, quit := c.inMessages, c.quit
+
+	for {
+		msg, err := ReadMessage(c, c.inChunkStreams, atomic.LoadUint32(&c.inChunkSize))
+		if err != nil {
+			log.Error("client receive: %s", err)
+			c.loopExited(err)
+			return
+		}
+
+		select {
+		case inMessages <- msg:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// sendLoop drains outMessages, chunking and writing each one to the wire.
+// It exits and reports the error via loopExited when a write fails, or
+// reports a clean exit once quit is closed by Reset. outMessages is never
+// closed (only abandoned on Reset), so draining it and waking up on quit
+// share a single select instead of racing a close.
+func (c *Client) sendLoop() {
+	outMessages, quit := c.outMessages, c.quit
+
+	for {
+		select {
+		case msg := <-outMessages:
+			if err := WriteMessage(c, c.outChunkStreams, atomic.LoadUint32(&c.outChunkSize), msg); err != nil {
+				log.Error("client send: %s", err)
+				c.loopExited(err)
+				return
+			}
+		case <-quit:
+			c.loopExited(nil)
+			return
+		}
+	}
+}
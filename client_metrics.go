@@ -0,0 +1,177 @@
+package rtmp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// MetricsSink lets a user plug byte, message and RTT accounting into
+// Prometheus/OpenTelemetry/etc. without forking the library. Methods are
+// called from the client's send/receive/route loops, from the handshake,
+// and from whichever goroutine calls SendMessage, so they may run
+// concurrently, and must not block.
+type MetricsSink interface {
+	RecordBytes(in, out uint32)
+	RecordMessage(chunkStreamId uint32, msgType uint8)
+	RecordRTT(rtt time.Duration)
+}
+
+// Stats is a point-in-time snapshot of a Client's bandwidth and protocol
+// state, as returned by Client.Stats.
+type Stats struct {
+	BytesIn  uint32
+	BytesOut uint32
+
+	InChunkSize   uint32
+	OutChunkSize  uint32
+	InWindowSize  uint32
+	OutWindowSize uint32
+
+	PendingCalls int
+
+	// ChunkStreamMessages counts messages seen per chunk stream id, in
+	// either direction, since the last Reset.
+	ChunkStreamMessages map[uint32]uint64
+}
+
+// Stats returns a snapshot of the client's current bandwidth accounting,
+// chunk/window configuration and in-flight call count.
+func (c *Client) Stats() Stats {
+	c.pendingMutex.Lock()
+	pendingCalls := len(c.pendingRequests)
+	c.pendingMutex.Unlock()
+
+	c.metricsMutex.Lock()
+	counts := make(map[uint32]uint64, len(c.chunkStreamMessages))
+	for csid, n := range c.chunkStreamMessages {
+		counts[csid] = n
+	}
+	c.metricsMutex.Unlock()
+
+	return Stats{
+		BytesIn:             atomic.LoadUint32(&c.inBytes),
+		BytesOut:            atomic.LoadUint32(&c.outBytes),
+		InChunkSize:         atomic.LoadUint32(&c.inChunkSize),
+		OutChunkSize:        atomic.LoadUint32(&c.outChunkSize),
+		InWindowSize:        atomic.LoadUint32(&c.inWindowSize),
+		OutWindowSize:       atomic.LoadUint32(&c.outWindowSize),
+		PendingCalls:        pendingCalls,
+		ChunkStreamMessages: counts,
+	}
+}
+
+// SetMetricsSink installs sink to receive byte/message/RTT events as they
+// happen. Pass nil to stop reporting.
+func (c *Client) SetMetricsSink(sink MetricsSink) {
+	c.metricsMutex.Lock()
+	defer c.metricsMutex.Unlock()
+	c.metricsSink = sink
+}
+
+func (c *Client) recordBytes(in, out uint32) {
+	c.metricsMutex.Lock()
+	sink := c.metricsSink
+	c.metricsMutex.Unlock()
+
+	if sink != nil {
+		sink.RecordBytes(in, out)
+	}
+}
+
+// recordMessage tallies msg against its chunk stream id and, if a
+// MetricsSink is installed, reports it. It is called from routeLoop for
+// inbound messages and from SendMessage for outbound ones.
+func (c *Client) recordMessage(chunkStreamId uint32, msgType uint8) {
+	c.metricsMutex.Lock()
+	c.chunkStreamMessages[chunkStreamId]++
+	sink := c.metricsSink
+	c.metricsMutex.Unlock()
+
+	if sink != nil {
+		sink.RecordMessage(chunkStreamId, msgType)
+	}
+}
+
+// SetOutChunkSize updates the chunk size Client uses for outgoing
+// messages and notifies the peer via the Set Chunk Size protocol control
+// message (type 1), so it knows to expect larger chunks. The default of
+// 128 bytes badly caps throughput for HD video; servers generally accept
+// anything up to 65536.
+func (c *Client) SetOutChunkSize(n uint32) error {
+	if err := c.sendProtocolControlMessage(MESSAGE_TYPE_SET_CHUNK_SIZE, n); err != nil {
+		return err
+	}
+	atomic.StoreUint32(&c.outChunkSize, n)
+	return nil
+}
+
+// SetAckWindowSize announces the window (in bytes) after which the client
+// expects the peer to send back a Window Acknowledgement Size message
+// acking our outgoing bytes, via the WindowAckSize protocol control
+// message (type 5). It updates OutWindowSize; InWindowSize tracks the
+// window the peer announces to us and is updated by handleProtocolMessage.
+func (c *Client) SetAckWindowSize(n uint32) error {
+	if err := c.sendProtocolControlMessage(MESSAGE_TYPE_WINDOW_ACK_SIZE, n); err != nil {
+		return err
+	}
+	atomic.StoreUint32(&c.outWindowSize, n)
+	return nil
+}
+
+// pingTimeout bounds how long a Ping waits for its Ping Response before
+// pendingPings forgets about it. Without this, a ping the peer never
+// answers (or one sent just before a reconnect reshuffles stream ids)
+// would sit in the map forever.
+const pingTimeout = 30 * time.Second
+
+// Ping sends a User Control Message Ping Request (type 4, event 6)
+// carrying the current time so the matching Ping Response (event 7) can
+// be used to compute round-trip time. The computed RTT is reported to
+// the installed MetricsSink, if any; callers that need the value
+// synchronously should register a sink and read it from there.
+func (c *Client) Ping() error {
+	ts := uint32(time.Now().UnixNano() / int64(time.Millisecond))
+
+	c.pingMutex.Lock()
+	c.pendingPings[ts] = time.Now()
+	c.pingMutex.Unlock()
+
+	time.AfterFunc(pingTimeout, func() { c.expirePing(ts) })
+
+	return c.sendUserControlMessage(USER_CONTROL_PING_REQUEST, ts)
+}
+
+// expirePing discards a pending ping that never got a matching Ping
+// Response, so a peer that stops responding doesn't leak pendingPings
+// entries indefinitely.
+func (c *Client) expirePing(ts uint32) {
+	c.pingMutex.Lock()
+	delete(c.pendingPings, ts)
+	c.pingMutex.Unlock()
+}
+
+// handlePong is called by handleProtocolMessage for an incoming Ping
+// Response (type 4, event 7) and resolves the RTT for the matching Ping
+// that this client originated.
+func (c *Client) handlePong(ts uint32) {
+	c.pingMutex.Lock()
+	sent, ok := c.pendingPings[ts]
+	if ok {
+		delete(c.pendingPings, ts)
+	}
+	c.pingMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	rtt := time.Since(sent)
+
+	c.metricsMutex.Lock()
+	sink := c.metricsSink
+	c.metricsMutex.Unlock()
+
+	if sink != nil {
+		sink.RecordRTT(rtt)
+	}
+}
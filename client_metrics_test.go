@@ -0,0 +1,120 @@
+package rtmp
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeMetricsSink struct {
+	mu       sync.Mutex
+	bytesIn  uint32
+	bytesOut uint32
+	messages int
+	rtts     []time.Duration
+}
+
+func (f *fakeMetricsSink) RecordBytes(in, out uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bytesIn += in
+	f.bytesOut += out
+}
+
+func (f *fakeMetricsSink) RecordMessage(chunkStreamId uint32, msgType uint8) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages++
+}
+
+func (f *fakeMetricsSink) RecordRTT(rtt time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rtts = append(f.rtts, rtt)
+}
+
+func TestRecordMessageTalliesByChunkStream(t *testing.T) {
+	c := NewClient("rtmp://example.com/live")
+
+	c.recordMessage(3, MESSAGE_TYPE_VIDEO)
+	c.recordMessage(3, MESSAGE_TYPE_VIDEO)
+	c.recordMessage(4, MESSAGE_TYPE_AUDIO)
+
+	stats := c.Stats()
+	if stats.ChunkStreamMessages[3] != 2 {
+		t.Errorf("ChunkStreamMessages[3] = %d, want 2", stats.ChunkStreamMessages[3])
+	}
+	if stats.ChunkStreamMessages[4] != 1 {
+		t.Errorf("ChunkStreamMessages[4] = %d, want 1", stats.ChunkStreamMessages[4])
+	}
+}
+
+func TestRecordBytesReportsToSink(t *testing.T) {
+	c := NewClient("rtmp://example.com/live")
+	sink := &fakeMetricsSink{}
+	c.SetMetricsSink(sink)
+
+	c.recordBytes(10, 0)
+	c.recordBytes(0, 20)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.bytesIn != 10 || sink.bytesOut != 20 {
+		t.Errorf("sink saw bytesIn=%d bytesOut=%d, want 10/20", sink.bytesIn, sink.bytesOut)
+	}
+}
+
+func TestHandlePongRecordsRTTAndClearsPending(t *testing.T) {
+	c := NewClient("rtmp://example.com/live")
+	sink := &fakeMetricsSink{}
+	c.SetMetricsSink(sink)
+
+	ts := uint32(12345)
+	c.pendingPings[ts] = time.Now().Add(-50 * time.Millisecond)
+
+	c.handlePong(ts)
+
+	sink.mu.Lock()
+	n := len(sink.rtts)
+	sink.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("expected exactly one RTT recorded, got %d", n)
+	}
+
+	c.pingMutex.Lock()
+	_, stillPending := c.pendingPings[ts]
+	c.pingMutex.Unlock()
+	if stillPending {
+		t.Error("handlePong should remove the entry from pendingPings")
+	}
+}
+
+func TestHandlePongIgnoresUnknownTimestamp(t *testing.T) {
+	c := NewClient("rtmp://example.com/live")
+	sink := &fakeMetricsSink{}
+	c.SetMetricsSink(sink)
+
+	c.handlePong(99999)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.rtts) != 0 {
+		t.Errorf("handlePong for an unknown ping should not record an RTT, got %d", len(sink.rtts))
+	}
+}
+
+func TestExpirePingRemovesPendingEntry(t *testing.T) {
+	c := NewClient("rtmp://example.com/live")
+
+	ts := uint32(555)
+	c.pendingPings[ts] = time.Now()
+
+	c.expirePing(ts)
+
+	c.pingMutex.Lock()
+	_, ok := c.pendingPings[ts]
+	c.pingMutex.Unlock()
+	if ok {
+		t.Error("expirePing should remove the pending entry so it doesn't leak")
+	}
+}
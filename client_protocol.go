@@ -0,0 +1,55 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+)
+
+// handleProtocolMessage handles a message on CHUNK_STREAM_ID_PROTOCOL: Set
+// Chunk Size, Window Acknowledgement Size and User Control Messages
+// (including Ping Request/Response), dispatched here by routeLoop instead
+// of being dropped on the floor as an unrecognized chunk stream.
+func (c *Client) handleProtocolMessage(msg *Message) {
+	buf := msg.Buf.Bytes()
+
+	switch msg.Type {
+	case MESSAGE_TYPE_SET_CHUNK_SIZE:
+		if len(buf) < 4 {
+			log.Error("client protocol: short Set Chunk Size message (%d bytes)", len(buf))
+			return
+		}
+		inChunkSize := binary.BigEndian.Uint32(buf)
+		atomic.StoreUint32(&c.inChunkSize, inChunkSize)
+		log.Debug("client protocol: peer set chunk size to %d", inChunkSize)
+
+	case MESSAGE_TYPE_WINDOW_ACK_SIZE:
+		if len(buf) < 4 {
+			log.Error("client protocol: short Window Ack Size message (%d bytes)", len(buf))
+			return
+		}
+		atomic.StoreUint32(&c.inWindowSize, binary.BigEndian.Uint32(buf))
+
+	case MESSAGE_TYPE_USER_CONTROL:
+		if len(buf) < 6 {
+			log.Error("client protocol: short User Control message (%d bytes)", len(buf))
+			return
+		}
+
+		event := binary.BigEndian.Uint16(buf[0:2])
+		value := binary.BigEndian.Uint32(buf[2:6])
+
+		switch event {
+		case USER_CONTROL_PING_REQUEST:
+			if err := c.sendUserControlMessage(USER_CONTROL_PING_RESPONSE, value); err != nil {
+				log.Error("client protocol: could not reply to ping request: %s", err)
+			}
+		case USER_CONTROL_PING_RESPONSE:
+			c.handlePong(value)
+		default:
+			log.Trace("client protocol: discarding user control event %d", event)
+		}
+
+	default:
+		log.Trace("client protocol: discarding message type %d", msg.Type)
+	}
+}
@@ -0,0 +1,218 @@
+package rtmp
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// ErrConnectionLost is delivered to pending Call/CallAsync waiters when the
+// underlying TCP connection drops. ConnectionListener.OnDisconnected
+// receives the I/O error that ended the connection instead.
+var ErrConnectionLost = Error("rtmp: connection lost")
+
+// ReconnectPolicy controls how Client redials after the connection is
+// lost. The delay before attempt n is InitialDelay * Factor^(n-1), capped
+// at MaxDelay and randomized by +/-Jitter.
+type ReconnectPolicy struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Factor       float64
+	Jitter       float64
+	MaxAttempts  int // 0 means unlimited
+}
+
+// DefaultReconnectPolicy is a reasonable starting point: 500ms up to 30s,
+// doubling each attempt, with 20% jitter and no attempt limit.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     30 * time.Second,
+	Factor:       2,
+	Jitter:       0.2,
+	MaxAttempts:  0,
+}
+
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	d := float64(p.InitialDelay) * pow(p.Factor, attempt)
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		d = d * (1 - p.Jitter + 2*p.Jitter*rand.Float64())
+	}
+
+	return time.Duration(d)
+}
+
+func pow(base float64, exp int) float64 {
+	result := 1.0
+	for i := 0; i < exp; i++ {
+		result *= base
+	}
+	return result
+}
+
+// ConnectionListener receives lifecycle notifications from a Client.
+// OnConnected and OnDisconnected fire whether or not a ReconnectPolicy is
+// installed; OnReconnecting fires only when one is. Implementations must
+// not block.
+type ConnectionListener interface {
+	OnConnected()
+	OnDisconnected(err error)
+	OnReconnecting(attempt int)
+}
+
+// SetReconnectPolicy installs p and enables automatic reconnect: when the
+// read/write/route loops exit because of an I/O error, Client redials,
+// rehandshakes and reconnects using the same URL instead of leaving the
+// connection silently dead.
+func (c *Client) SetReconnectPolicy(p ReconnectPolicy) {
+	c.reconnectMutex.Lock()
+	defer c.reconnectMutex.Unlock()
+	c.reconnectPolicy = &p
+}
+
+// AddConnectionListener registers l to be notified of connect, disconnect
+// and reconnect-attempt events.
+func (c *Client) AddConnectionListener(l ConnectionListener) {
+	c.listenersMutex.Lock()
+	defer c.listenersMutex.Unlock()
+	c.listeners = append(c.listeners, l)
+}
+
+func (c *Client) notifyConnected() {
+	for _, l := range c.snapshotListeners() {
+		l.OnConnected()
+	}
+}
+
+func (c *Client) notifyDisconnected(err error) {
+	for _, l := range c.snapshotListeners() {
+		l.OnDisconnected(err)
+	}
+}
+
+func (c *Client) notifyReconnecting(attempt int) {
+	for _, l := range c.snapshotListeners() {
+		l.OnReconnecting(attempt)
+	}
+}
+
+func (c *Client) snapshotListeners() []ConnectionListener {
+	c.listenersMutex.Lock()
+	defer c.listenersMutex.Unlock()
+	return append([]ConnectionListener(nil), c.listeners...)
+}
+
+// loopExited is called by receiveLoop, sendLoop and routeLoop when they
+// return because of an I/O error (nil err on a clean Disconnect). The
+// first call wins: supervise only acts on the first value it receives, and
+// loopDone has room for one value per loop, so later calls from sibling
+// loops never block. It holds
+// resetMutex while touching loopDone so it can never race a concurrent
+// Reset closing and reassigning that same field.
+func (c *Client) loopExited(err error) {
+	c.resetMutex.Lock()
+	defer c.resetMutex.Unlock()
+
+	select {
+	case c.loopDone <- err:
+	default:
+	}
+}
+
+// supervise waits for a loop to exit and, if a ReconnectPolicy is
+// installed, drives redial/rehandshake/reconnect using the same URL and
+// auth providers that were active. It is started once per successful
+// Connect, and snapshots loopDone under resetMutex like SendMessage does,
+// so it can never race a concurrent Reset reassigning that field.
+func (c *Client) supervise() {
+	c.resetMutex.Lock()
+	loopDone := c.loopDone
+	c.resetMutex.Unlock()
+
+	err := <-loopDone
+	if err == nil {
+		return
+	}
+
+	c.setConnected(false)
+	c.failPendingCalls(ErrConnectionLost)
+	c.notifyDisconnected(err)
+
+	c.reconnectMutex.Lock()
+	policy := c.reconnectPolicy
+	c.reconnectMutex.Unlock()
+
+	if policy == nil {
+		return
+	}
+
+	// Snapshot the streams that were active before Reset wipes the
+	// registry, so they can be recreated against the new connection once
+	// it's back up.
+	streams := c.snapshotStreams()
+
+	for attempt := 1; policy.MaxAttempts == 0 || attempt <= policy.MaxAttempts; attempt++ {
+		c.notifyReconnecting(attempt)
+
+		time.Sleep(policy.delay(attempt - 1))
+
+		// Reset before redialing: the dead connection's inMessages/
+		// outMessages/loopDone channels are still open but nothing will
+		// ever service them again, so Connect must get fresh ones rather
+		// than start new loops against channels receive/sendLoop already
+		// abandoned.
+		c.Reset()
+
+		if err := c.Connect(); err != nil {
+			log.Warn("reconnect attempt %d to %s failed: %s", attempt, c.url, err)
+			continue
+		}
+
+		log.Debug("reconnected to %s after %d attempt(s)", c.url, attempt)
+
+		c.replayStreams(streams)
+
+		return
+	}
+
+	log.Error("giving up reconnecting to %s: reconnect policy exhausted", c.url)
+}
+
+// replayStreams recreates each previously active Stream against the new
+// connection and reissues its last play/publish call, so callers holding
+// a *Stream from before the reconnect keep receiving on the same
+// channels without having to notice the connection dropped.
+func (c *Client) replayStreams(streams []*Stream) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, s := range streams {
+		if err := s.replay(ctx); err != nil {
+			log.Error("reconnect: could not replay stream %q: %s", s.lastAction, err)
+		}
+	}
+}
+
+func (c *Client) setConnected(v bool) {
+	c.connectedMutex.Lock()
+	c.connected = v
+	c.connectedMutex.Unlock()
+}
+
+// failPendingCalls wakes every registered Call/CallAsync waiter with err,
+// so callers blocked on a response return promptly instead of waiting out
+// their own context deadline against a connection that is never coming
+// back.
+func (c *Client) failPendingCalls(err error) {
+	c.pendingMutex.Lock()
+	pending := c.pendingRequests
+	c.pendingRequests = make(map[uint32]chan pendingResult)
+	c.pendingMutex.Unlock()
+
+	for _, ch := range pending {
+		ch <- pendingResult{err: err}
+	}
+}
@@ -0,0 +1,69 @@
+package rtmp
+
+import "testing"
+
+func TestPow(t *testing.T) {
+	tests := []struct {
+		base float64
+		exp  int
+		want float64
+	}{
+		{2, 0, 1},
+		{2, 1, 2},
+		{2, 4, 16},
+		{1.5, 3, 3.375},
+	}
+
+	for _, tt := range tests {
+		if got := pow(tt.base, tt.exp); got != tt.want {
+			t.Errorf("pow(%v, %v) = %v, want %v", tt.base, tt.exp, got, tt.want)
+		}
+	}
+}
+
+func TestReconnectPolicyDelayCapsAtMaxDelay(t *testing.T) {
+	p := ReconnectPolicy{
+		InitialDelay: 1,
+		MaxDelay:     100,
+		Factor:       10,
+		Jitter:       0,
+	}
+
+	// Without a cap, attempt 5 would be 1 * 10^5 = 100000.
+	if d := p.delay(5); d > 100 {
+		t.Errorf("delay(5) = %v, want capped at MaxDelay 100", d)
+	}
+}
+
+func TestReconnectPolicyDelayGrowsWithAttempt(t *testing.T) {
+	p := ReconnectPolicy{
+		InitialDelay: 10,
+		MaxDelay:     0,
+		Factor:       2,
+		Jitter:       0,
+	}
+
+	d0 := p.delay(0)
+	d1 := p.delay(1)
+	d2 := p.delay(2)
+
+	if !(d0 < d1 && d1 < d2) {
+		t.Fatalf("expected strictly increasing delays, got %v, %v, %v", d0, d1, d2)
+	}
+}
+
+func TestReconnectPolicyDelayJitterStaysInBounds(t *testing.T) {
+	p := ReconnectPolicy{
+		InitialDelay: 1000,
+		MaxDelay:     0,
+		Factor:       1,
+		Jitter:       0.2,
+	}
+
+	for i := 0; i < 50; i++ {
+		d := p.delay(0)
+		if d < 800 || d > 1200 {
+			t.Fatalf("delay(0) = %v, want within +/-20%% of 1000", d)
+		}
+	}
+}
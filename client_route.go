@@ -1,23 +1,39 @@
 package rtmp
 
+// routeLoop dispatches messages handed off by receiveLoop until quit is
+// closed by Reset. inMessages is never closed (only abandoned on Reset),
+// so it is read alongside quit in a single select rather than relying on
+// a channel-close to signal shutdown.
 func (c *Client) routeLoop() {
-	for {
-		msg, open := <-c.inMessages
+	inMessages, quit := c.inMessages, c.quit
 
-		log.Trace("client route: received message: %#v", msg)
+	for {
+		var msg *Message
 
-		if !open {
-			log.Trace("client route: channel closed, exiting")
+		select {
+		case msg = <-inMessages:
+		case <-quit:
+			log.Trace("client route: stopped, exiting")
+			c.loopExited(nil)
 			return
 		}
 
+		log.Trace("client route: received message: %#v", msg)
+
+		c.recordMessage(msg.ChunkStreamId, msg.Type)
+
 		switch msg.ChunkStreamId {
 		case CHUNK_STREAM_ID_PROTOCOL:
 			c.handleProtocolMessage(msg)
 		case CHUNK_STREAM_ID_COMMAND:
 			c.routeCommandMessage(msg)
 		default:
-			log.Warn("discarding message on unknown chunk stream %d: +%v", msg.ChunkStreamId, msg)
+			switch msg.Type {
+			case MESSAGE_TYPE_VIDEO, MESSAGE_TYPE_AUDIO, MESSAGE_TYPE_AMF0_DATA, MESSAGE_TYPE_AMF3_DATA:
+				c.routeStreamMessage(msg)
+			default:
+				log.Warn("discarding message on unknown chunk stream %d: +%v", msg.ChunkStreamId, msg)
+			}
 		}
 	}
 }
@@ -31,7 +47,7 @@ func (c *Client) routeCommandMessage(msg *Message) {
 
 	tid := uint32(result.TransactionId)
 
-	c.resultsMutex.Lock()
-	c.results[tid] = result
-	c.resultsMutex.Unlock()
+	if !c.deliverResponse(tid, result) {
+		log.Trace("client route: no pending caller for transaction %d, discarding", tid)
+	}
 }
@@ -0,0 +1,94 @@
+package rtmp
+
+import (
+	"context"
+	"testing"
+)
+
+// drainOutMessages absorbs everything SendMessage hands to c.outMessages,
+// standing in for the sendLoop goroutine Connect would normally start.
+func drainOutMessages(c *Client) {
+	go func() {
+		for range c.outMessages {
+		}
+	}()
+}
+
+func TestCallAsyncDeliversResponse(t *testing.T) {
+	c := NewClient("rtmp://example.com/live")
+	drainOutMessages(c)
+
+	msg, err := NewCommandMessage(CHUNK_STREAM_ID_COMMAND, 0, c.NextTransactionId(), "connect", nil)
+	if err != nil {
+		t.Fatalf("NewCommandMessage: %s", err)
+	}
+
+	ch, err := c.CallAsync(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("CallAsync: %s", err)
+	}
+
+	want := &Response{}
+	if !c.deliverResponse(msg.TransactionId, want) {
+		t.Fatal("deliverResponse found no waiter registered for this transaction")
+	}
+
+	result := <-ch
+	if result.Err != nil {
+		t.Fatalf("result.Err = %s, want nil", result.Err)
+	}
+	if result.Response != want {
+		t.Fatal("result.Response does not match the delivered response")
+	}
+}
+
+func TestCallAsyncReportsCtxErrOnCancel(t *testing.T) {
+	c := NewClient("rtmp://example.com/live")
+	drainOutMessages(c)
+
+	msg, err := NewCommandMessage(CHUNK_STREAM_ID_COMMAND, 0, c.NextTransactionId(), "connect", nil)
+	if err != nil {
+		t.Fatalf("NewCommandMessage: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := c.CallAsync(ctx, msg)
+	if err != nil {
+		t.Fatalf("CallAsync: %s", err)
+	}
+
+	cancel()
+
+	result := <-ch
+	if result.Err != context.Canceled {
+		t.Fatalf("result.Err = %v, want context.Canceled", result.Err)
+	}
+	if result.Response != nil {
+		t.Fatal("result.Response should be nil when ctx is cancelled")
+	}
+}
+
+func TestCallAsyncReportsConnectionLost(t *testing.T) {
+	c := NewClient("rtmp://example.com/live")
+	drainOutMessages(c)
+
+	msg, err := NewCommandMessage(CHUNK_STREAM_ID_COMMAND, 0, c.NextTransactionId(), "connect", nil)
+	if err != nil {
+		t.Fatalf("NewCommandMessage: %s", err)
+	}
+
+	ch, err := c.CallAsync(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("CallAsync: %s", err)
+	}
+
+	c.failPendingCalls(ErrConnectionLost)
+
+	result := <-ch
+	if result.Err != ErrConnectionLost {
+		t.Fatalf("result.Err = %v, want ErrConnectionLost", result.Err)
+	}
+	if result.Response != nil {
+		t.Fatal("result.Response should be nil when the connection is lost")
+	}
+}
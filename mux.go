@@ -0,0 +1,104 @@
+package rtmp
+
+import (
+	"path"
+	"sync"
+)
+
+// Mux routes a Session to a Handler based on the app/streamName the
+// client publishes or plays to, the RTMP analogue of http.ServeMux.
+// Patterns may end in "/*" to match any stream name under an app, e.g.
+// "live/*" matches "live/cam1", "live/cam2", etc.
+type Mux struct {
+	mu      sync.Mutex
+	publish []muxEntry
+	play    []muxEntry
+}
+
+type muxEntry struct {
+	pattern string
+	handler Handler
+}
+
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// HandlePublish registers h to serve sessions whose first NetStream
+// command is "publish" to a name matching pattern.
+func (m *Mux) HandlePublish(pattern string, h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publish = append(m.publish, muxEntry{pattern, h})
+}
+
+// HandlePlay registers h to serve sessions whose first NetStream command
+// is "play" for a name matching pattern.
+func (m *Mux) HandlePlay(pattern string, h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.play = append(m.play, muxEntry{pattern, h})
+}
+
+// ServeRTMP implements Handler: it waits for the session's first publish
+// or play request, matches its app/streamName against the registered
+// patterns, and hands the session to the winning Handler. The stream
+// request that was consumed to decide routing is replayed so the matched
+// Handler's own AcceptPublish/AcceptPlay still observes it.
+func (m *Mux) ServeRTMP(sess *Session) {
+	select {
+	case req, ok := <-sess.publishRequests:
+		if !ok {
+			return
+		}
+		if h := m.match(m.publish, sess.app, req.stream.name); h != nil {
+			sess.publishRequests <- req
+			h.ServeRTMP(sess)
+			return
+		}
+		log.Warn("mux: no publish handler for %s/%s", sess.app, req.stream.name)
+	case req, ok := <-sess.playRequests:
+		if !ok {
+			return
+		}
+		if h := m.match(m.play, sess.app, req.stream.name); h != nil {
+			sess.playRequests <- req
+			h.ServeRTMP(sess)
+			return
+		}
+		log.Warn("mux: no play handler for %s/%s", sess.app, req.stream.name)
+	}
+}
+
+func (m *Mux) match(entries []muxEntry, app, name string) Handler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target := path.Join(app, name)
+
+	for _, e := range entries {
+		if matchPattern(e.pattern, target) {
+			return e.handler
+		}
+	}
+
+	return nil
+}
+
+func matchPattern(pattern, target string) bool {
+	if rest, ok := stripSuffix(pattern, "/*"); ok {
+		return target == rest || stripPrefix(target, rest+"/")
+	}
+	return pattern == target
+}
+
+func stripSuffix(s, suffix string) (string, bool) {
+	if len(s) >= len(suffix) && s[len(s)-len(suffix):] == suffix {
+		return s[:len(s)-len(suffix)], true
+	}
+	return s, false
+}
+
+func stripPrefix(s, prefix string) bool {
+	return len(s) >= len(prefix) && s[:len(prefix)] == prefix
+}
@@ -0,0 +1,52 @@
+package rtmp
+
+import "testing"
+
+func TestMatchPatternExact(t *testing.T) {
+	if !matchPattern("live/cam1", "live/cam1") {
+		t.Error("expected exact pattern to match identical target")
+	}
+	if matchPattern("live/cam1", "live/cam2") {
+		t.Error("expected exact pattern not to match a different target")
+	}
+}
+
+func TestMatchPatternWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		target  string
+		want    bool
+	}{
+		{"live/*", "live/cam1", true},
+		{"live/*", "live/cam2", true},
+		{"live/*", "live", true},
+		{"live/*", "vod/cam1", false},
+		{"live/*", "livestream/cam1", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.target); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestStripSuffix(t *testing.T) {
+	rest, ok := stripSuffix("live/*", "/*")
+	if !ok || rest != "live" {
+		t.Errorf("stripSuffix(\"live/*\", \"/*\") = (%q, %v), want (\"live\", true)", rest, ok)
+	}
+
+	if _, ok := stripSuffix("live", "/*"); ok {
+		t.Error("stripSuffix should not match a pattern without the suffix")
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	if !stripPrefix("live/cam1", "live/") {
+		t.Error("expected stripPrefix to match")
+	}
+	if stripPrefix("vod/cam1", "live/") {
+		t.Error("expected stripPrefix not to match a different prefix")
+	}
+}
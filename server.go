@@ -0,0 +1,164 @@
+package rtmp
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/elobuff/goamf"
+)
+
+// Handler responds to a single RTMP connection. ServeRTMP is called once
+// per accepted Session, in its own goroutine, and owns that Session for
+// its lifetime: when ServeRTMP returns, the Session is closed.
+type Handler interface {
+	ServeRTMP(sess *Session)
+}
+
+// HandlerFunc adapts a plain function to the Handler interface, the same
+// way net/http.HandlerFunc does.
+type HandlerFunc func(sess *Session)
+
+func (f HandlerFunc) ServeRTMP(sess *Session) {
+	f(sess)
+}
+
+// ConnectRequest describes the AMF connect command a client sent when
+// establishing a Session, so Server.OnConnect can inspect or reject it
+// before the session is handed to Handler.
+type ConnectRequest struct {
+	App    string
+	URL    string
+	Params amf.Object
+}
+
+// Server is the mirror image of Client: it accepts inbound RTMP/RTMPS
+// connections, performs the server side of the handshake and AMF
+// connect, and hands each resulting Session to Handler, analogous to
+// net/http.Server and its Handler.
+type Server struct {
+	Handler Handler
+
+	// TLSConfig is used by ListenAndServeTLS and, when set, by Serve to
+	// wrap its listener in TLS.
+	TLSConfig *tls.Config
+
+	// OnConnect, if set, is called with the client's AMF connect command
+	// before the session is accepted. Returning an error rejects the
+	// connection with NetConnection.Connect.Rejected instead of handing
+	// it to Handler.
+	OnConnect func(*ConnectRequest) error
+
+	listener net.Listener
+}
+
+// ListenAndServe listens on addr and serves RTMP connections, blocking
+// until the listener is closed or accept fails permanently.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// ListenAndServeTLS is the RTMPS counterpart of ListenAndServe: it loads
+// certFile/keyFile (unless s.TLSConfig already has certificates) and
+// serves RTMP over TLS.
+func (s *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	config := s.TLSConfig
+	if config == nil {
+		config = &tls.Config{}
+	}
+
+	if len(config.Certificates) == 0 {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	l, err := tls.Listen("tcp", addr, config)
+	if err != nil {
+		return err
+	}
+
+	// l is already TLS-wrapped by tls.Listen above, so accept directly
+	// instead of going through Serve, which would wrap it in TLS a
+	// second time.
+	return s.serve(l)
+}
+
+// Serve accepts connections on l until it returns an error, handing each
+// one to a new Session served by s.Handler in its own goroutine. If
+// TLSConfig is set, l is wrapped with tls.NewListener first; Serve does
+// not check whether l is already TLS-wrapped. ListenAndServeTLS does not
+// go through Serve, so its listener is wrapped only once.
+func (s *Server) Serve(l net.Listener) error {
+	if s.TLSConfig != nil {
+		l = tls.NewListener(l, s.TLSConfig)
+	}
+
+	return s.serve(l)
+}
+
+func (s *Server) serve(l net.Listener) error {
+	s.listener = l
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting new connections; in-flight Sessions are left
+// running.
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	sess := newSession(conn)
+	defer sess.close()
+
+	log.Debug("server: accepted connection from %s", conn.RemoteAddr())
+
+	if err := sess.serverHandshake(); err != nil {
+		log.Error("server: handshake with %s failed: %s", conn.RemoteAddr(), err)
+		return
+	}
+
+	go sess.receiveLoop()
+	go sess.sendLoop()
+
+	req, err := sess.awaitConnect()
+	if err != nil {
+		log.Error("server: connect from %s failed: %s", conn.RemoteAddr(), err)
+		return
+	}
+
+	if s.OnConnect != nil {
+		if err := s.OnConnect(req); err != nil {
+			log.Warn("server: rejecting connect from %s: %s", conn.RemoteAddr(), err)
+			sess.rejectConnect(err)
+			return
+		}
+	}
+
+	if err := sess.acceptConnect(req); err != nil {
+		log.Error("server: could not accept connect from %s: %s", conn.RemoteAddr(), err)
+		return
+	}
+
+	if s.Handler != nil {
+		s.Handler.ServeRTMP(sess)
+	}
+}
@@ -0,0 +1,209 @@
+package rtmp
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/elobuff/goamf"
+)
+
+// IncomingStream is a NetStream a connected client is publishing to this
+// server. It mirrors Stream's receive side: media arrives on Video/Audio/
+// Metadata as the client sends it.
+type IncomingStream struct {
+	id   uint32
+	name string
+	kind string
+
+	video    chan *VideoTag
+	audio    chan *AudioTag
+	metadata chan amf.Object
+}
+
+func (s *IncomingStream) Name() string               { return s.name }
+func (s *IncomingStream) Video() <-chan *VideoTag     { return s.video }
+func (s *IncomingStream) Audio() <-chan *AudioTag     { return s.audio }
+func (s *IncomingStream) Metadata() <-chan amf.Object { return s.metadata }
+
+func (s *IncomingStream) dispatchVideo(tag *VideoTag) {
+	select {
+	case s.video <- tag:
+	default:
+		log.Warn("incoming stream %d: video channel full, dropping tag", s.id)
+	}
+}
+
+func (s *IncomingStream) dispatchAudio(tag *AudioTag) {
+	select {
+	case s.audio <- tag:
+	default:
+		log.Warn("incoming stream %d: audio channel full, dropping tag", s.id)
+	}
+}
+
+func (s *IncomingStream) dispatchMetadata(data amf.Object) {
+	select {
+	case s.metadata <- data:
+	default:
+		log.Warn("incoming stream %d: metadata channel full, dropping", s.id)
+	}
+}
+
+func (s *IncomingStream) closeChannels() {
+	close(s.video)
+	close(s.audio)
+	close(s.metadata)
+}
+
+// OutgoingStream is a NetStream a connected client is playing from this
+// server. It mirrors Stream's send side.
+type OutgoingStream struct {
+	id   uint32
+	name string
+
+	sess *Session
+}
+
+func (s *OutgoingStream) Name() string { return s.name }
+
+func (s *OutgoingStream) WriteVideo(ts uint32, data []byte) error {
+	return s.sess.client.sendMediaMessage(MESSAGE_TYPE_VIDEO, s.id, ts, data)
+}
+
+func (s *OutgoingStream) WriteAudio(ts uint32, data []byte) error {
+	return s.sess.client.sendMediaMessage(MESSAGE_TYPE_AUDIO, s.id, ts, data)
+}
+
+// Session represents one accepted RTMP connection on the server side,
+// after the handshake and AMF connect have completed. It is the server
+// analogue of Client: the same chunk/message plumbing runs underneath,
+// inverted for the server role.
+type Session struct {
+	conn net.Conn
+
+	// client embeds the same chunk-stream/message-loop machinery Client
+	// uses, run with the handshake sides inverted, so routing, pending
+	// calls and stream dispatch are implemented exactly once.
+	client *Client
+
+	app    string
+	url    string
+	params amf.Object
+
+	publishRequests chan *publishRequest
+	playRequests    chan *playRequest
+
+	mu sync.Mutex
+}
+
+type publishRequest struct {
+	stream *IncomingStream
+}
+
+type playRequest struct {
+	stream *OutgoingStream
+}
+
+func newSession(conn net.Conn) *Session {
+	return &Session{
+		conn:            conn,
+		client:          newServerSideClient(conn),
+		publishRequests: make(chan *publishRequest, 1),
+		playRequests:    make(chan *playRequest, 1),
+	}
+}
+
+// App is the application name the client connected to (the first path
+// segment of the connect URL, e.g. "live" in rtmp://host/live).
+func (sess *Session) App() string { return sess.app }
+
+// URL is the full tcUrl the client supplied in its connect command.
+func (sess *Session) URL() string { return sess.url }
+
+// createStream issues a NetConnection "createStream" command to the
+// connected client and returns the NetStream id it assigns, the
+// server-initiated counterpart of Client.CreateStream. It is needed
+// because Publish/Play below push media the client never asked for, so
+// unlike the routeLoop "publish"/"play" cases there is no client-run
+// createStream to take the id from.
+func (sess *Session) createStream(ctx context.Context) (uint32, error) {
+	msg, err := sess.client.newCommandMessage(CHUNK_STREAM_ID_COMMAND, 0, "createStream", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := sess.client.Call(ctx, msg)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.StreamId()
+}
+
+// Publish starts publishing name as kind ("live", "record" or "append")
+// to this session, i.e. the server pushes media down to the client, the
+// mirror image of AcceptPublish.
+func (sess *Session) Publish(ctx context.Context, name, kind string) (*OutgoingStream, error) {
+	id, err := sess.createStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &OutgoingStream{id: id, name: name, sess: sess}
+	if err := sess.client.sendCommand(CHUNK_STREAM_ID_COMMAND, stream.id, "publish", name, kind); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Play starts playback of name from this session, i.e. the server
+// requests the client send media, the mirror image of AcceptPlay. The
+// resulting IncomingStream is registered for dispatch immediately, since
+// the client may start sending video/audio as soon as it sees the play
+// command.
+func (sess *Session) Play(ctx context.Context, name string) (*IncomingStream, error) {
+	id, err := sess.createStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &IncomingStream{
+		id:       id,
+		name:     name,
+		video:    make(chan *VideoTag, 256),
+		audio:    make(chan *AudioTag, 256),
+		metadata: make(chan amf.Object, 16),
+	}
+	sess.client.registerMediaSink(stream.id, stream)
+
+	if err := sess.client.sendCommand(CHUNK_STREAM_ID_COMMAND, stream.id, "play", name); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// AcceptPublish blocks until the client issues a NetStream "publish"
+// command and returns the resulting IncomingStream.
+func (sess *Session) AcceptPublish() (*IncomingStream, error) {
+	req, ok := <-sess.publishRequests
+	if !ok {
+		return nil, ErrConnectionLost
+	}
+	return req.stream, nil
+}
+
+// AcceptPlay blocks until the client issues a NetStream "play" command
+// and returns the resulting OutgoingStream.
+func (sess *Session) AcceptPlay() (*OutgoingStream, error) {
+	req, ok := <-sess.playRequests
+	if !ok {
+		return nil, ErrConnectionLost
+	}
+	return req.stream, nil
+}
+
+func (sess *Session) close() {
+	sess.client.Reset()
+	sess.conn.Close()
+}
@@ -0,0 +1,135 @@
+package rtmp
+
+import (
+	"net"
+
+	"github.com/elobuff/goamf"
+)
+
+// newServerSideClient wraps conn in a Client purely to reuse its chunk
+// stream bookkeeping, pending-request registry and stream dispatch; it is
+// never Connect()ed or Dial()ed, only driven directly by Session.
+func newServerSideClient(conn net.Conn) *Client {
+	c := &Client{}
+	c.Reset()
+	c.conn = conn
+	return c
+}
+
+// serverHandshake performs the server side (S0/S1/S2) of the RTMP
+// handshake, the inverse of Client.handshake's C0/C1/C2.
+func (sess *Session) serverHandshake() error {
+	return ServerHandshake(sess.conn)
+}
+
+func (sess *Session) receiveLoop() {
+	sess.client.receiveLoop()
+}
+
+func (sess *Session) sendLoop() {
+	sess.client.sendLoop()
+}
+
+// awaitConnect waits for the client's AMF "connect" command and parses it
+// into a ConnectRequest without yet replying, so Server.OnConnect gets a
+// chance to accept or reject it first.
+func (sess *Session) awaitConnect() (*ConnectRequest, error) {
+	var msg *Message
+
+	select {
+	case msg = <-sess.client.inMessages:
+	case <-sess.client.quit:
+		return nil, ErrConnectionLost
+	}
+
+	cmd, err := msg.DecodeCommand(&sess.client.dec)
+	if err != nil {
+		return nil, Error("session: could not decode connect command: %s", err)
+	}
+
+	app, _ := cmd.Params["app"].(string)
+	tcUrl, _ := cmd.Params["tcUrl"].(string)
+
+	sess.app = app
+	sess.url = tcUrl
+	sess.params = cmd.Params
+
+	return &ConnectRequest{App: app, URL: tcUrl, Params: cmd.Params}, nil
+}
+
+// acceptConnect replies with NetConnection.Connect.Success and starts the
+// route loop that feeds publishRequests/playRequests.
+func (sess *Session) acceptConnect(req *ConnectRequest) error {
+	if err := sess.client.sendCommand(CHUNK_STREAM_ID_COMMAND, 0, "_result", amf.Object{
+		"level": "status",
+		"code":  "NetConnection.Connect.Success",
+	}); err != nil {
+		return err
+	}
+
+	go sess.routeLoop()
+
+	return nil
+}
+
+// rejectConnect replies with NetConnection.Connect.Rejected and the
+// OnConnect error as the description.
+func (sess *Session) rejectConnect(err error) {
+	sess.client.sendCommand(CHUNK_STREAM_ID_COMMAND, 0, "_error", amf.Object{
+		"level":       "status",
+		"code":        "NetConnection.Connect.Rejected",
+		"description": err.Error(),
+	})
+}
+
+// routeLoop dispatches NetStream commands (publish/play) and media
+// messages for the lifetime of the session, the server-side counterpart
+// of Client.routeLoop.
+func (sess *Session) routeLoop() {
+	for {
+		var msg *Message
+
+		select {
+		case msg = <-sess.client.inMessages:
+		case <-sess.client.quit:
+			close(sess.publishRequests)
+			close(sess.playRequests)
+			return
+		}
+
+		if msg.ChunkStreamId != CHUNK_STREAM_ID_COMMAND {
+			sess.client.routeStreamMessage(msg)
+			continue
+		}
+
+		cmd, err := msg.DecodeCommand(&sess.client.dec)
+		if err != nil {
+			log.Error("session: could not decode command: %s", err)
+			continue
+		}
+
+		switch cmd.Name {
+		case "publish":
+			name, _ := cmd.Args[0].(string)
+			kind, _ := cmd.Args[1].(string)
+			stream := &IncomingStream{
+				id:       msg.StreamId,
+				name:     name,
+				kind:     kind,
+				video:    make(chan *VideoTag, 256),
+				audio:    make(chan *AudioTag, 256),
+				metadata: make(chan amf.Object, 16),
+			}
+			sess.client.registerMediaSink(stream.id, stream)
+			sess.publishRequests <- &publishRequest{stream: stream}
+		case "play":
+			name, _ := cmd.Args[0].(string)
+			stream := &OutgoingStream{id: msg.StreamId, name: name, sess: sess}
+			sess.playRequests <- &playRequest{stream: stream}
+		default:
+			if !sess.client.deliverResponse(uint32(cmd.TransactionId), cmd) {
+				log.Trace("session: no pending caller for transaction %d, discarding", cmd.TransactionId)
+			}
+		}
+	}
+}
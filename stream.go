@@ -0,0 +1,396 @@
+package rtmp
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/elobuff/goamf"
+)
+
+// VideoTag is a single FLV video tag as delivered on a Stream's Video()
+// channel: the RTMP timestamp in milliseconds and the raw FLV video tag
+// body (codec id nibble included, exactly as received on the wire).
+type VideoTag struct {
+	Timestamp uint32
+	Data      []byte
+}
+
+// AudioTag is the audio counterpart of VideoTag.
+type AudioTag struct {
+	Timestamp uint32
+	Data      []byte
+}
+
+// mediaSink is anything routeStreamMessage can dispatch Video/Audio/Notify
+// messages to by NetStream id. Both the client-side Stream (from
+// CreateStream) and the server-side IncomingStream (from a client's
+// publish) implement it, so a single registry and dispatch path serves
+// both roles.
+type mediaSink interface {
+	dispatchVideo(tag *VideoTag)
+	dispatchAudio(tag *AudioTag)
+	dispatchMetadata(data amf.Object)
+	closeChannels()
+}
+
+// Stream wraps a single NetStream (a message stream id within the
+// client's connection) and exposes the standard play/publish commands
+// plus typed channels for the media it receives.
+type Stream struct {
+	client *Client
+	id     uint32
+
+	video    chan *VideoTag
+	audio    chan *AudioTag
+	metadata chan amf.Object
+
+	// lastAction/lastArgs record the most recent play/publish call so a
+	// reconnect can replay it against the new NetStream id; see
+	// Client.supervise.
+	replayMutex sync.Mutex
+	lastAction  string
+	lastArgs    []interface{}
+}
+
+// CreateStream issues a NetConnection "createStream" command and returns
+// the Stream wrapping the id the server assigns. The route loop will
+// start dispatching any Video/Audio/Notify messages addressed to that
+// stream id to the returned Stream's channels.
+func (c *Client) CreateStream(ctx context.Context) (*Stream, error) {
+	msg, err := c.newCommandMessage(CHUNK_STREAM_ID_COMMAND, 0, "createStream", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.Call(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.StreamId()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stream{
+		client:   c,
+		id:       id,
+		video:    make(chan *VideoTag, 256),
+		audio:    make(chan *AudioTag, 256),
+		metadata: make(chan amf.Object, 16),
+	}
+
+	c.registerStream(s)
+
+	return s, nil
+}
+
+// Id is the NetStream's message stream id, as assigned by the server in
+// response to createStream.
+func (s *Stream) Id() uint32 {
+	return s.id
+}
+
+// Video delivers video tags whose MessageStreamId matches this stream.
+func (s *Stream) Video() <-chan *VideoTag {
+	return s.video
+}
+
+// Audio delivers audio tags whose MessageStreamId matches this stream.
+func (s *Stream) Audio() <-chan *AudioTag {
+	return s.audio
+}
+
+// Metadata delivers onMetaData (and other AMF data message) payloads.
+func (s *Stream) Metadata() <-chan amf.Object {
+	return s.metadata
+}
+
+func (s *Stream) dispatchVideo(tag *VideoTag) {
+	select {
+	case s.video <- tag:
+	default:
+		log.Warn("stream %d: video channel full, dropping tag", s.id)
+	}
+}
+
+func (s *Stream) dispatchAudio(tag *AudioTag) {
+	select {
+	case s.audio <- tag:
+	default:
+		log.Warn("stream %d: audio channel full, dropping tag", s.id)
+	}
+}
+
+func (s *Stream) dispatchMetadata(data amf.Object) {
+	select {
+	case s.metadata <- data:
+	default:
+		log.Warn("stream %d: metadata channel full, dropping", s.id)
+	}
+}
+
+func (s *Stream) closeChannels() {
+	close(s.video)
+	close(s.audio)
+	close(s.metadata)
+}
+
+// Play issues the standard NetStream "play" command: name is the stream
+// name to play, start/duration follow the RTMP spec (-2/-1 sentinels
+// meaning "live or recorded"/"until end"), and reset clears any
+// previously buffered data on the client when true.
+func (s *Stream) Play(name string, start, duration int32, reset bool) error {
+	s.recordAction("play", name, start, duration, reset)
+	return s.invoke("play", name, start, duration, reset)
+}
+
+// Publish issues the standard NetStream "publish" command. kind is one of
+// "live", "record" or "append".
+func (s *Stream) Publish(name, kind string) error {
+	s.recordAction("publish", name, kind)
+	return s.invoke("publish", name, kind)
+}
+
+func (s *Stream) recordAction(name string, args ...interface{}) {
+	s.replayMutex.Lock()
+	s.lastAction = name
+	s.lastArgs = args
+	s.replayMutex.Unlock()
+}
+
+// replay reissues createStream against the client's current connection
+// and, if play/publish had been called before the connection dropped,
+// replays that call against the new NetStream id - so a Stream a caller
+// is holding keeps working transparently across a reconnect instead of
+// going silently stale.
+func (s *Stream) replay(ctx context.Context) error {
+	msg, err := s.client.newCommandMessage(CHUNK_STREAM_ID_COMMAND, 0, "createStream", nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.client.Call(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.StreamId()
+	if err != nil {
+		return err
+	}
+
+	s.client.remapStream(s.id)
+	s.id = id
+	s.client.registerStream(s)
+
+	s.replayMutex.Lock()
+	action, args := s.lastAction, s.lastArgs
+	s.replayMutex.Unlock()
+
+	if action == "" {
+		return nil
+	}
+
+	return s.client.sendCommand(CHUNK_STREAM_ID_COMMAND, s.id, action, args...)
+}
+
+// Pause issues the NetStream "pause" command.
+func (s *Stream) Pause(pause bool, ts int32) error {
+	return s.invoke("pause", pause, ts)
+}
+
+// Seek issues the NetStream "seek" command.
+func (s *Stream) Seek(ts int32) error {
+	return s.invoke("seek", ts)
+}
+
+// DeleteStream releases the server-side NetStream and stops further
+// dispatch to this Stream's channels.
+func (s *Stream) DeleteStream() error {
+	s.client.unregisterStream(s.id)
+	return s.client.sendCommand(CHUNK_STREAM_ID_COMMAND, 0, "deleteStream", s.id)
+}
+
+// ReceiveAudio toggles whether the server sends audio on this stream.
+func (s *Stream) ReceiveAudio(enable bool) error {
+	return s.invoke("receiveAudio", enable)
+}
+
+// ReceiveVideo toggles whether the server sends video on this stream.
+func (s *Stream) ReceiveVideo(enable bool) error {
+	return s.invoke("receiveVideo", enable)
+}
+
+func (s *Stream) invoke(name string, args ...interface{}) error {
+	return s.client.sendCommand(CHUNK_STREAM_ID_COMMAND, s.id, name, args...)
+}
+
+// WriteVideo sends a raw FLV video tag body on this stream, for use by a
+// publisher that already has encoded frames.
+func (s *Stream) WriteVideo(ts uint32, data []byte) error {
+	return s.client.sendMediaMessage(MESSAGE_TYPE_VIDEO, s.id, ts, data)
+}
+
+// WriteAudio sends a raw FLV audio tag body on this stream.
+func (s *Stream) WriteAudio(ts uint32, data []byte) error {
+	return s.client.sendMediaMessage(MESSAGE_TYPE_AUDIO, s.id, ts, data)
+}
+
+// WriteFLV writes every tag received on this stream to w as a standard
+// FLV file: the 9-byte FLV header followed by interleaved audio/video
+// tags, each prefixed by the previous tag's size as FLV requires. It
+// blocks until the stream's channels are closed (DeleteStream) or w
+// returns an error.
+func (s *Stream) WriteFLV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.Write([]byte{'F', 'L', 'V', 0x01, 0x05, 0, 0, 0, 9}); err != nil {
+		return err
+	}
+
+	prevTagSize := uint32(0)
+	writeTag := func(tagType byte, ts uint32, data []byte) error {
+		if err := binary.Write(bw, binary.BigEndian, prevTagSize); err != nil {
+			return err
+		}
+
+		header := make([]byte, 11)
+		header[0] = tagType
+		putUint24(header[1:4], uint32(len(data)))
+		putUint24(header[4:7], ts&0xffffff)
+		header[7] = byte(ts >> 24)
+
+		if _, err := bw.Write(header); err != nil {
+			return err
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
+		}
+
+		prevTagSize = uint32(len(header) + len(data))
+		return nil
+	}
+
+	for {
+		select {
+		case tag, ok := <-s.video:
+			if !ok {
+				return bw.Flush()
+			}
+			if err := writeTag(MESSAGE_TYPE_VIDEO, tag.Timestamp, tag.Data); err != nil {
+				return err
+			}
+		case tag, ok := <-s.audio:
+			if !ok {
+				return bw.Flush()
+			}
+			if err := writeTag(MESSAGE_TYPE_AUDIO, tag.Timestamp, tag.Data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v >> 16)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v)
+}
+
+// snapshotStreams returns the client-side Streams currently registered,
+// for the reconnect supervisor to recreate after a redial. Server-side
+// IncomingStreams are deliberately excluded: those belong to a Session
+// tied to one inbound TCP connection, which a Client reconnect has no
+// relationship to.
+func (c *Client) snapshotStreams() []*Stream {
+	c.streamsMutex.Lock()
+	defer c.streamsMutex.Unlock()
+
+	out := make([]*Stream, 0, len(c.streams))
+	for _, sink := range c.streams {
+		if s, ok := sink.(*Stream); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// registerStream makes s the dispatch target for messages whose
+// MessageStreamId equals s.id.
+func (c *Client) registerStream(s *Stream) {
+	c.registerMediaSink(s.id, s)
+}
+
+// registerMediaSink makes sink the dispatch target for messages whose
+// MessageStreamId equals id. Used for both client-side Streams (from
+// CreateStream) and server-side IncomingStreams (from a client's
+// publish).
+func (c *Client) registerMediaSink(id uint32, sink mediaSink) {
+	c.streamsMutex.Lock()
+	defer c.streamsMutex.Unlock()
+	c.streams[id] = sink
+}
+
+// remapStream drops the mapping for a Stream's previous id without
+// closing its channels, used when replay() gives an existing Stream a
+// fresh id after a reconnect.
+func (c *Client) remapStream(oldId uint32) {
+	c.streamsMutex.Lock()
+	defer c.streamsMutex.Unlock()
+	delete(c.streams, oldId)
+}
+
+// unregisterStream removes id from the dispatch table and closes its
+// channels under the same streamsMutex routeStreamMessage dispatches
+// under, so a DeleteStream racing an in-flight message for this stream id
+// either closes before the lookup (dispatch sees it's gone and never
+// sends) or after the send has already completed under the lock -
+// never mid-send, which is what made a concurrent close a "send on
+// closed channel" panic before.
+func (c *Client) unregisterStream(id uint32) {
+	c.streamsMutex.Lock()
+	defer c.streamsMutex.Unlock()
+
+	sink, ok := c.streams[id]
+	if !ok {
+		return
+	}
+	delete(c.streams, id)
+
+	sink.closeChannels()
+}
+
+// routeStreamMessage dispatches a Video/Audio/Notify message to the
+// mediaSink registered for its MessageStreamId, if any. It is called from
+// routeLoop alongside handleProtocolMessage/routeCommandMessage. The
+// lookup and the send happen under the same streamsMutex unregisterStream
+// closes under, so they can never interleave with a concurrent
+// DeleteStream for this stream id.
+func (c *Client) routeStreamMessage(msg *Message) {
+	c.streamsMutex.Lock()
+	defer c.streamsMutex.Unlock()
+
+	sink, ok := c.streams[msg.StreamId]
+	if !ok {
+		log.Trace("client route: no stream registered for id %d, discarding", msg.StreamId)
+		return
+	}
+
+	switch msg.Type {
+	case MESSAGE_TYPE_VIDEO:
+		sink.dispatchVideo(&VideoTag{Timestamp: msg.Timestamp, Data: msg.Buf.Bytes()})
+	case MESSAGE_TYPE_AUDIO:
+		sink.dispatchAudio(&AudioTag{Timestamp: msg.Timestamp, Data: msg.Buf.Bytes()})
+	case MESSAGE_TYPE_AMF0_DATA, MESSAGE_TYPE_AMF3_DATA:
+		data, err := msg.DecodeObject(&c.dec)
+		if err != nil {
+			log.Error("client route: unable to decode metadata for stream %d: %s", msg.StreamId, err)
+			return
+		}
+		sink.dispatchMetadata(data)
+	}
+}